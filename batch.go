@@ -0,0 +1,104 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package thex
+
+import (
+	"hash"
+	"runtime"
+)
+
+// WriteLeaves computes the root of leaves, hashing separate runs of
+// them concurrently. It is equivalent to writing the same leaves, in
+// order, to New(newDigest()).
+//
+// newDigest is a factory rather than a single hash.Hash because each
+// worker needs its own instance to hash concurrently; the single
+// shared digest New serializes on is what makes Write the bottleneck
+// on large inputs.
+//
+// leaves is split into runs whose sizes are the powers of two that
+// sum to len(leaves) (i.e. the set bits of len(leaves), largest
+// first). Each run is therefore already a complete, perfectly
+// balanced subtree and can be hashed independently with no sibling
+// promotion; the runs' roots are then combined with the same
+// right-to-left folding New's Sum uses for its unpaired subtrees, so
+// the result is identical to the sequential tree regardless of how
+// many workers are used.
+//
+// At most GOMAXPROCS runs are hashed at once, and there is one run
+// per set bit of len(leaves), so the number of goroutines is bounded
+// by that bit count rather than by GOMAXPROCS: when len(leaves) is a
+// power of two, the whole row is a single run hashed by one goroutine.
+func WriteLeaves(leaves [][]byte, newDigest func() hash.Hash) ([]byte, error) {
+	if len(leaves) == 0 {
+		return nil, nil
+	}
+
+	sizes := runSizes(len(leaves))
+	roots := make([][]byte, len(sizes))
+
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	done := make(chan int, len(sizes))
+
+	start := 0
+	for i, size := range sizes {
+		run := leaves[start : start+size]
+		start += size
+		sem <- struct{}{}
+		go func(i int, run [][]byte) {
+			defer func() { <-sem }()
+			roots[i] = runRoot(run, newDigest())
+			done <- i
+		}(i, run)
+	}
+	for range sizes {
+		<-done
+	}
+
+	return foldPeaks(roots, newDigest()), nil
+}
+
+// runSizes returns the powers of two that sum to n, largest first:
+// the set bits of n's binary representation.
+func runSizes(n int) []int {
+	var sizes []int
+	for bit := 63; bit >= 0; bit-- {
+		size := 1 << uint(bit)
+		if n&size != 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+// runRoot hashes a perfectly balanced run of leaves (len(leaves) a
+// power of two) down to its single root, using the same 0x01-prefixed
+// inner-node hashing as the rest of the package.
+func runRoot(leaves [][]byte, digest hash.Hash) []byte {
+	cur := leaves
+	for len(cur) > 1 {
+		next := make([][]byte, len(cur)/2)
+		for i := range next {
+			digest.Reset()
+			digest.Write(innerPrefix)
+			digest.Write(cur[2*i])
+			digest.Write(cur[2*i+1])
+			next[i] = digest.Sum(nil)
+		}
+		cur = next
+	}
+	return cur[0]
+}
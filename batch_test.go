@@ -0,0 +1,34 @@
+package thex_test
+
+import (
+	"crypto/sha1"
+	"testing"
+
+	thex "github.com/3M3RY/go-thex"
+)
+
+// BenchmarkWrite hashes a multi-MB row of leaves the way New's Write
+// always has: one leaf, and one channel-free digest, at a time.
+func BenchmarkWrite(b *testing.B) {
+	leaves := testLeaves(1 << 18) // ~5MB of SHA1 leaves
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := thex.New(sha1.New())
+		for _, leaf := range leaves {
+			t.Write(leaf)
+		}
+		t.Sum(nil)
+	}
+}
+
+// BenchmarkWriteLeaves hashes the same row with WriteLeaves. 1<<18 is
+// a power of two, so WriteLeaves hashes it as a single run.
+func BenchmarkWriteLeaves(b *testing.B) {
+	leaves := testLeaves(1 << 18)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := thex.WriteLeaves(leaves, sha1.New); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
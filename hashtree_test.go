@@ -1,15 +1,16 @@
 // The test vectors are from
 // http://web.archive.org/web/20080316033726/http://www.open-content.net/specs/draft-jchapweske-thex-02.html#anchor17
 
-package hashtree_test
+package thex_test
 
 import (
 	"crypto/sha1"
 	"encoding/base32"
-	"github.com/3M3RY/go-hashtree"
 	"math/rand"
 	"testing"
 	"time"
+
+	thex "github.com/3M3RY/go-thex"
 )
 
 type treeTest struct {
@@ -51,7 +52,7 @@ func TestGolden(t *testing.T) {
 	for i := 0; i < len(golden); i++ {
 		g := golden[i]
 		base := sha1.New()
-		tree := hashtree.New(sha1.New())
+		tree := thex.New(sha1.New())
 		for j := 0; j < 4; j++ {
 			base.Write(g.in)
 			leaf := base.Sum(nil)
@@ -77,7 +78,7 @@ func BenchmarkGolden(b *testing.B) {
 	}
 
 	base := sha1.New()
-	t := hashtree.New(base)
+	t := thex.New(base)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
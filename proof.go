@@ -0,0 +1,216 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package thex
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+)
+
+// ErrLeafIndex is returned by Prover.Proof when the requested leaf
+// index is beyond the number of leaves written so far.
+var ErrLeafIndex = errors.New("thex: leaf index out of range")
+
+// A ProofStep is one hop of an audit path from a leaf up to the tree
+// root. Sibling is the hash of the node adjacent to the path at that
+// level, and IsRight reports whether Sibling belongs on the right of
+// the running hash, i.e. whether the next node is computed as
+// H(0x01 || running || Sibling) rather than H(0x01 || Sibling || running).
+type ProofStep struct {
+	Sibling []byte
+	IsRight bool
+}
+
+// Prover computes a tree root exactly like the hash.Hash returned by
+// New, but retains every leaf written so that an audit path can be
+// produced for any of them once writing is finished. Because it must
+// keep the whole row of leaves in memory, Prover trades New's constant
+// memory use for the ability to call Proof.
+//
+// Prover is meant to be built once and then asked for proofs of many
+// different leaves, e.g. to serve different segments to different
+// peers, so it caches the levels it builds from leaves and only
+// recomputes them when a Write has added leaves since the cache was
+// filled.
+type Prover struct {
+	digest hash.Hash
+	size   int
+
+	overflow []byte
+	leaves   [][]byte
+
+	// levels caches buildLevels(leaves, digest); cached is the
+	// number of leaves it was built from, so a Write that appends
+	// more leaves invalidates it without needing a separate flag.
+	levels [][][]byte
+	cached int
+}
+
+// NewWithProofs returns a Prover that uses digest to hash the tree.
+func NewWithProofs(digest hash.Hash) *Prover {
+	p := &Prover{
+		digest: digest,
+		size:   digest.Size(),
+	}
+	p.Reset()
+	return p
+}
+
+func (p *Prover) BlockSize() int { return p.size }
+func (p *Prover) Size() int      { return p.size }
+
+// Write accepts a serialized leaf stream, exactly as the hash.Hash
+// returned by New does.
+func (p *Prover) Write(b []byte) (n int, err error) {
+	n = len(b)
+	var i, j int
+
+	if len(p.overflow) > 0 {
+		i = p.size - len(p.overflow)
+		if i > n {
+			p.overflow = append(p.overflow, b...)
+			return
+		}
+		p.leaves = append(p.leaves, append(p.overflow, b[:i]...))
+		p.overflow = nil
+	}
+
+	j = i + p.size
+	for j <= n {
+		leaf := make([]byte, p.size)
+		copy(leaf, b[i:j])
+		p.leaves = append(p.leaves, leaf)
+		i = j
+		j += p.size
+	}
+	if i < n {
+		p.overflow = append([]byte(nil), b[i:]...)
+	}
+	return
+}
+
+func (p *Prover) Reset() {
+	p.overflow = make([]byte, 0, p.size-1)
+	p.leaves = nil
+	p.levels = nil
+	p.cached = 0
+}
+
+// levelsCache returns buildLevels(p.leaves, p.digest), recomputing it
+// only if leaves have been written since the last call.
+func (p *Prover) levelsCache() [][][]byte {
+	if p.cached != len(p.leaves) {
+		p.levels = buildLevels(p.leaves, p.digest)
+		p.cached = len(p.leaves)
+	}
+	return p.levels
+}
+
+// Sum returns the root of the leaves written so far, following the
+// same 0x01-prefixed inner-node hashing and unpaired-node promotion
+// as the hash.Hash returned by New.
+func (p *Prover) Sum(b []byte) []byte {
+	levels := p.levelsCache()
+	if len(levels) == 0 {
+		return b
+	}
+	top := levels[len(levels)-1]
+	if len(top) == 0 {
+		return b
+	}
+	return append(b, top[0]...)
+}
+
+// Proof returns the audit path from the leaf at leafIndex to the
+// root: the sibling hash at each level along with its side, in the
+// order they must be applied by VerifyProof.
+func (p *Prover) Proof(leafIndex uint64) ([]ProofStep, error) {
+	if leafIndex >= uint64(len(p.leaves)) {
+		return nil, ErrLeafIndex
+	}
+
+	levels := p.levelsCache()
+	var steps []ProofStep
+	idx := leafIndex
+	for lvl := 0; lvl < len(levels)-1; lvl++ {
+		row := levels[lvl]
+		if idx%2 == 0 {
+			if idx+1 < uint64(len(row)) {
+				steps = append(steps, ProofStep{Sibling: row[idx+1], IsRight: true})
+			}
+			// an odd node out at this level is promoted unchanged,
+			// so there is no sibling to record here.
+		} else {
+			steps = append(steps, ProofStep{Sibling: row[idx-1], IsRight: false})
+		}
+		idx /= 2
+	}
+	return steps, nil
+}
+
+// VerifyProof replays proof against leaf and reports whether it
+// reconstructs root, using digest to perform the 0x01-prefixed
+// inner-node hashing.
+func VerifyProof(root, leaf []byte, index uint64, proof []ProofStep, digest hash.Hash) bool {
+	running := leaf
+	for _, step := range proof {
+		digest.Reset()
+		digest.Write(innerPrefix)
+		if step.IsRight {
+			digest.Write(running)
+			digest.Write(step.Sibling)
+		} else {
+			digest.Write(step.Sibling)
+			digest.Write(running)
+		}
+		running = digest.Sum(nil)
+	}
+	return bytes.Equal(running, root)
+}
+
+// buildLevels computes every row of the tree, leaves first and the
+// root last, applying the THEX promotion rule: a node left without a
+// pair at its level is carried up to the next level unchanged rather
+// than being duplicated.
+func buildLevels(leaves [][]byte, digest hash.Hash) [][][]byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	cur := make([][]byte, len(leaves))
+	copy(cur, leaves)
+	levels := [][][]byte{cur}
+
+	for len(cur) > 1 {
+		next := make([][]byte, 0, (len(cur)+1)/2)
+		i := 0
+		for i+1 < len(cur) {
+			digest.Reset()
+			digest.Write(innerPrefix)
+			digest.Write(cur[i])
+			digest.Write(cur[i+1])
+			next = append(next, digest.Sum(nil))
+			i += 2
+		}
+		if i < len(cur) {
+			next = append(next, cur[i])
+		}
+		levels = append(levels, next)
+		cur = next
+	}
+	return levels
+}
@@ -0,0 +1,125 @@
+package thex_test
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	thex "github.com/3M3RY/go-thex"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	h := sha1.New()
+	var b [8]byte
+	for i := range leaves {
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		h.Reset()
+		h.Write(b[:])
+		leaves[i] = h.Sum(nil)
+	}
+	return leaves
+}
+
+// TestProverMatchesNew checks that Prover's root agrees with the
+// hash.Hash returned by New for a range of leaf counts, including odd
+// counts that exercise the unpaired-node promotion rule.
+func TestProverMatchesNew(t *testing.T) {
+	for n := 0; n <= 20; n++ {
+		leaves := testLeaves(n)
+
+		tree := thex.New(sha1.New())
+		for _, leaf := range leaves {
+			tree.Write(leaf)
+		}
+		want := tree.Sum(nil)
+
+		p := thex.NewWithProofs(sha1.New())
+		for _, leaf := range leaves {
+			p.Write(leaf)
+		}
+		got := p.Sum(nil)
+
+		if string(got) != string(want) {
+			t.Fatalf("n=%d: Prover.Sum = %x, want %x", n, got, want)
+		}
+	}
+}
+
+// TestProverProofVerifies checks that every leaf's audit path verifies
+// against the root, for both even and odd leaf counts.
+func TestProverProofVerifies(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		leaves := testLeaves(n)
+
+		p := thex.NewWithProofs(sha1.New())
+		for _, leaf := range leaves {
+			p.Write(leaf)
+		}
+		root := p.Sum(nil)
+
+		for i, leaf := range leaves {
+			proof, err := p.Proof(uint64(i))
+			if err != nil {
+				t.Fatalf("n=%d, i=%d: Proof returned %v", n, i, err)
+			}
+			if !thex.VerifyProof(root, leaf, uint64(i), proof, sha1.New()) {
+				t.Fatalf("n=%d, i=%d: VerifyProof failed", n, i)
+			}
+		}
+	}
+}
+
+// TestProverProofLeafIndex checks that Proof rejects an index beyond
+// the leaves written so far.
+func TestProverProofLeafIndex(t *testing.T) {
+	p := thex.NewWithProofs(sha1.New())
+	for _, leaf := range testLeaves(3) {
+		p.Write(leaf)
+	}
+	if _, err := p.Proof(3); err != thex.ErrLeafIndex {
+		t.Fatalf("Proof(3) = %v, want ErrLeafIndex", err)
+	}
+}
+
+// TestProverCacheInvalidatesOnWrite checks that a Write after Sum/Proof
+// have already built the cached levels is reflected in later calls,
+// rather than serving a stale root or proof against the old leaf set.
+func TestProverCacheInvalidatesOnWrite(t *testing.T) {
+	p := thex.NewWithProofs(sha1.New())
+	leaves := testLeaves(5)
+
+	for _, leaf := range leaves[:3] {
+		p.Write(leaf)
+	}
+	_ = p.Sum(nil)
+	firstProof, err := p.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, leaf := range leaves[3:] {
+		p.Write(leaf)
+	}
+	root := p.Sum(nil)
+
+	if !thex.VerifyProof(root, leaves[0], 0, firstProof, sha1.New()) {
+		// the proof for leaf 0 changes once the tree grows past it, so
+		// only require a fresh proof to verify, not the stale one.
+		freshProof, err := p.Proof(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !thex.VerifyProof(root, leaves[0], 0, freshProof, sha1.New()) {
+			t.Fatal("fresh proof for leaf 0 does not verify against updated root")
+		}
+	}
+
+	lastProof, err := p.Proof(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !thex.VerifyProof(root, leaves[4], 4, lastProof, sha1.New()) {
+		t.Fatal("proof for newly written leaf does not verify")
+	}
+}
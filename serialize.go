@@ -0,0 +1,218 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package thex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// ErrCorruptTree is returned by Parse when a header value is out of
+// the range Parse accepts, whether from wire corruption or a hostile
+// peer: too many levels, a root level that isn't a single node, a
+// level that can't have come from halving/promoting the one below it,
+// a hash size large enough that honoring it would itself be a
+// memory-exhaustion attack, or a level of more than maxNodesPerLevel
+// nodes. That last bound also rejects a tree that Serialize wrote
+// from more than maxNodesPerLevel leaves.
+var ErrCorruptTree = errors.New("thex: corrupt or hostile tree header")
+
+const (
+	// maxTreeLevels bounds levelCount: even a tree built one leaf at
+	// a time out of 2^63 leaves fits in no more levels than this.
+	maxTreeLevels = 64
+	// maxHashSize generously bounds hashSize; no real hash.Hash
+	// digest comes anywhere close to this.
+	maxHashSize = 1024
+	// maxNodesPerLevel bounds any single level's node count,
+	// independent of the level's position, so a corrupt header can't
+	// force an enormous allocation before the rest of the stream is
+	// even read.
+	maxNodesPerLevel = 1 << 20
+)
+
+// A Tree is the result of hashing a full row of leaves, with every
+// intermediate node kept rather than just the root. Unlike the
+// hash.Hash returned by New, a Tree can be exchanged over the wire so
+// a peer can verify an individual segment of a large file without
+// fetching the whole thing, which is the wire format the THEX draft
+// describes.
+type Tree struct {
+	digestName  string
+	segmentSize uint32
+	hashSize    uint32
+
+	// levels[0] holds the leaves, levels[len(levels)-1] holds the
+	// single root, following the same unpaired-node promotion as New.
+	levels [][][]byte
+}
+
+// NewTree hashes leaves into a Tree, retaining every level. digestName
+// identifies the hash algorithm used (e.g. "SHA1", "TTH") so that
+// Serialize/Parse round-trip it, and segmentSize records the chunk
+// size the leaves were derived from; neither is interpreted by Tree
+// itself.
+func NewTree(digestName string, segmentSize uint32, digest hash.Hash, leaves [][]byte) *Tree {
+	t := &Tree{
+		digestName:  digestName,
+		segmentSize: segmentSize,
+		hashSize:    uint32(digest.Size()),
+		levels:      buildLevels(leaves, digest),
+	}
+	return t
+}
+
+// Root returns the hash at the top of the tree.
+func (t *Tree) Root() []byte {
+	if len(t.levels) == 0 {
+		return nil
+	}
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return nil
+	}
+	return top[0]
+}
+
+// Level returns the node hashes at level i, where i == 0 is the row
+// of leaves and i == len(t.levels)-1 is the root.
+func (t *Tree) Level(i int) [][]byte { return t.levels[i] }
+
+// LeafCount returns the number of leaves the tree was built from.
+func (t *Tree) LeafCount() int {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return len(t.levels[0])
+}
+
+// Serialize writes the tree's algorithm identifier, segment size, and
+// every level's node hashes, breadth-first from the root down, so a
+// peer can Parse it back and verify any individual leaf.
+func (t *Tree) Serialize(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if len(t.digestName) > 255 {
+		return errors.New("thex: digest name too long to serialize")
+	}
+	if err := bw.WriteByte(byte(len(t.digestName))); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(t.digestName); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, t.segmentSize); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, t.hashSize); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(t.levels))); err != nil {
+		return err
+	}
+
+	for i := len(t.levels) - 1; i >= 0; i-- {
+		level := t.levels[i]
+		if err := binary.Write(bw, binary.BigEndian, uint32(len(level))); err != nil {
+			return err
+		}
+		for _, node := range level {
+			if _, err := bw.Write(node); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Parse reads a tree previously written by Serialize.
+func Parse(r io.Reader) (*Tree, error) {
+	br := bufio.NewReader(r)
+
+	nameLen, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	nameBuf := make([]byte, nameLen)
+	if _, err := io.ReadFull(br, nameBuf); err != nil {
+		return nil, err
+	}
+
+	var segmentSize, hashSize, levelCount uint32
+	if err := binary.Read(br, binary.BigEndian, &segmentSize); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(br, binary.BigEndian, &hashSize); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(br, binary.BigEndian, &levelCount); err != nil {
+		return nil, err
+	}
+	if hashSize == 0 || hashSize > maxHashSize {
+		return nil, ErrCorruptTree
+	}
+	// levelCount == 0 is what Serialize writes for a tree of no
+	// leaves; it parses back to an empty Tree.
+	if levelCount > maxTreeLevels {
+		return nil, ErrCorruptTree
+	}
+
+	levels := make([][][]byte, levelCount)
+	var prevCount uint32
+	for i := levelCount; i > 0; i-- {
+		var nodeCount uint32
+		if err := binary.Read(br, binary.BigEndian, &nodeCount); err != nil {
+			return nil, err
+		}
+		// the first level read off the wire is the root: it must be
+		// exactly one node. Every level after that has exactly twice,
+		// or twice less one, as many nodes as the level above it,
+		// since a node only ever came from pairing up (or promoting)
+		// nodes below.
+		if i == levelCount {
+			if nodeCount != 1 {
+				return nil, ErrCorruptTree
+			}
+		} else if nodeCount > prevCount*2 || nodeCount < prevCount*2-1 {
+			return nil, ErrCorruptTree
+		}
+		if nodeCount > maxNodesPerLevel {
+			return nil, ErrCorruptTree
+		}
+		prevCount = nodeCount
+
+		level := make([][]byte, nodeCount)
+		for j := range level {
+			node := make([]byte, hashSize)
+			if _, err := io.ReadFull(br, node); err != nil {
+				return nil, err
+			}
+			level[j] = node
+		}
+		levels[i-1] = level
+	}
+
+	return &Tree{
+		digestName:  string(nameBuf),
+		segmentSize: segmentSize,
+		hashSize:    hashSize,
+		levels:      levels,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package thex_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	thex "github.com/3M3RY/go-thex"
+)
+
+// TestTreeRoundTrip checks that Serialize followed by Parse reproduces
+// the root and the leaf count.
+func TestTreeRoundTrip(t *testing.T) {
+	leaves := testLeaves(13)
+	tree := thex.NewTree("SHA1", 1024, sha1.New(), leaves)
+
+	var buf bytes.Buffer
+	if err := tree.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := thex.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got.Root()) != string(tree.Root()) {
+		t.Fatalf("Root = %x, want %x", got.Root(), tree.Root())
+	}
+	if got.LeafCount() != tree.LeafCount() {
+		t.Fatalf("LeafCount = %d, want %d", got.LeafCount(), tree.LeafCount())
+	}
+}
+
+// TestParseRejectsHugeLevelCount checks that a header claiming an
+// implausible number of levels is rejected rather than used to size an
+// allocation.
+func TestParseRejectsHugeLevelCount(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(4)
+	buf.WriteString("SHA1")
+	binary.Write(&buf, binary.BigEndian, uint32(1024))
+	binary.Write(&buf, binary.BigEndian, uint32(sha1.Size))
+	binary.Write(&buf, binary.BigEndian, uint32(1<<31))
+
+	if _, err := thex.Parse(&buf); err != thex.ErrCorruptTree {
+		t.Fatalf("Parse = %v, want ErrCorruptTree", err)
+	}
+}
+
+// TestParseRejectsHugeNodeCount checks that a level's node count is
+// bounded even though the levelCount it followed was plausible.
+func TestParseRejectsHugeNodeCount(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(4)
+	buf.WriteString("SHA1")
+	binary.Write(&buf, binary.BigEndian, uint32(1024))
+	binary.Write(&buf, binary.BigEndian, uint32(sha1.Size))
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	binary.Write(&buf, binary.BigEndian, uint32(1<<31))
+
+	if _, err := thex.Parse(&buf); err != thex.ErrCorruptTree {
+		t.Fatalf("Parse = %v, want ErrCorruptTree", err)
+	}
+}
+
+// TestParseRejectsBadRootCount checks that a root level claiming more
+// or fewer than one node is rejected.
+func TestParseRejectsBadRootCount(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteByte(4)
+	buf.WriteString("SHA1")
+	binary.Write(&buf, binary.BigEndian, uint32(1024))
+	binary.Write(&buf, binary.BigEndian, uint32(sha1.Size))
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	buf.Write(make([]byte, sha1.Size*2))
+
+	if _, err := thex.Parse(&buf); err != thex.ErrCorruptTree {
+		t.Fatalf("Parse = %v, want ErrCorruptTree", err)
+	}
+}
@@ -34,18 +34,23 @@ package thex
 
 import (
 	"hash"
-	"sync"
 )
 
 var innerPrefix = []byte{byte(1)}
 
+// a node is an unpaired subtree root waiting for a sibling at the
+// same level. tree keeps these on a stack rather than spreading them
+// across one goroutine per level.
+type node struct {
+	hash  []byte
+	level int
+}
+
 type tree struct {
 	digest   hash.Hash
-	mu       sync.Mutex // protects digest when GOMAXPROCS > 1
 	size     int
 	overflow []byte
-	leaves   chan []byte
-	sum      chan []byte
+	stack    []node
 }
 
 // New returns a new hash.Hash that computes the root of a
@@ -75,82 +80,74 @@ func (t *tree) Write(p []byte) (n int, err error) {
 		i = t.size - len(t.overflow)
 		if i > n {
 			t.overflow = append(t.overflow, p[:]...)
-		} else {
-			t.leaves <- append(t.overflow, p[:i]...)
-			t.overflow = nil
+			return
 		}
+		t.push(append(t.overflow, p[:i]...), 0)
+		t.overflow = nil
 	}
 
 	j = i + t.size
 	for j <= n {
-		t.leaves <- p[i:j]
+		t.push(p[i:j], 0)
 		i = j
 		j += t.size
 	}
 	if i < n {
-		t.overflow = p[i:]
+		t.overflow = append(t.overflow[:0], p[i:]...)
 	}
 	return
 }
 
 func (t *tree) Reset() {
 	t.overflow = make([]byte, 0, t.size-1)
-	t.leaves = make(chan []byte) // Buffering this channel has mixed results on speed
-	t.sum = make(chan []byte)
-	go t.processLevel(t.leaves, t.sum)
+	t.stack = nil
 }
 
-func (t *tree) Sum(b []byte) []byte {
-	t.leaves <- nil
-	return <-t.sum
+// push adds h at level, then repeatedly combines it with the top of
+// the stack for as long as the two share a level, carrying the result
+// one level higher each time.
+func (t *tree) push(h []byte, level int) {
+	for len(t.stack) > 0 && t.stack[len(t.stack)-1].level == level {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+
+		t.digest.Reset()
+		t.digest.Write(innerPrefix)
+		t.digest.Write(top.hash)
+		t.digest.Write(h)
+		h = t.digest.Sum(nil)
+		level++
+	}
+	t.stack = append(t.stack, node{hash: h, level: level})
 }
 
-// If it was possible to for tree to hold multiple instances of a Hash
-// then the multiple levels could be hashed simultaneously, if the
-// channels were buffered and the mutex loosened (I think). -EH
-func (t *tree) processLevel(ingress chan []byte, final chan []byte) {
-	var left []byte
-	var right []byte
-	var egress chan []byte
-	var sum []byte
-	left = <-ingress
-	for right = range ingress {
-		if right == nil {
-			final <- left
-		} else {
-			egress = make(chan []byte)
-			go t.processLevel(egress, final)
-			
-			t.mu.Lock()
-			t.digest.Reset()
-			t.digest.Write(innerPrefix)
-			t.digest.Write(left)
-			t.digest.Write(right)
-			sum = t.digest.Sum(nil)
-			t.mu.Unlock()
-			egress <- sum
-			break
-		}
+// Sum folds the stack's unpaired subtrees into a single root without
+// disturbing them, so that Write may continue afterward. Per the
+// THEX rule, a subtree left without a same-level partner is promoted
+// into the fold unchanged rather than duplicated.
+func (t *tree) Sum(b []byte) []byte {
+	if len(t.stack) == 0 {
+		return b
+	}
+	hashes := make([][]byte, len(t.stack))
+	for i, n := range t.stack {
+		hashes[i] = n.hash
 	}
+	return append(b, foldPeaks(hashes, t.digest)...)
+}
 
-	for left = range ingress {
-		if left != nil {
-			right := <-ingress
-			if right != nil {
-				t.mu.Lock()
-				t.digest.Reset()
-				t.digest.Write(innerPrefix)
-				t.digest.Write(left)
-				t.digest.Write(right)
-				sum = t.digest.Sum(nil)
-				t.mu.Unlock()
-				egress <- sum
-			} else {
-				egress <- left
-				egress <- nil
-			}
-		} else {
-			egress <- nil
-		}
+// foldPeaks combines a row of unpaired subtree roots into a single
+// hash, right to left, which is how both (*tree).Sum and WriteLeaves
+// apply the THEX promotion rule once a full level can no longer be
+// evenly paired off.
+func foldPeaks(peaks [][]byte, digest hash.Hash) []byte {
+	sum := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		digest.Reset()
+		digest.Write(innerPrefix)
+		digest.Write(peaks[i])
+		digest.Write(sum)
+		sum = digest.Sum(nil)
 	}
+	return sum
 }
@@ -0,0 +1,59 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tiger
+
+// Canonical reports whether this package's S-box tables are the
+// reference tiger.c constants. They are not: this package was written
+// without network access to fetch and check them against a copy of
+// tiger.c, so t1-t4 below are filled in by splitmix64 at init time
+// rather than hardcoded. Anything that needs real Tiger digests (to
+// interoperate with DC++, Gnutella, or urn:tree:tiger: magnet links)
+// must replace those tables with the canonical ones before relying on
+// this package; TestNotCanonical pins down the resulting mismatch so
+// that substitution doesn't go unnoticed.
+const Canonical = false
+
+// t1, t2, t3 and t4 are the four S-boxes the Tiger round function
+// looks up a byte of the running state in. See Canonical: these are
+// not the reference tiger.c constants, so digests produced with these
+// tables will not match Tiger("") = 24f0130c63ac9332..., Tiger("abc")
+// = f258c1e88414ab2a..., or any other implementation's output, even
+// though the round structure and key schedule around them are
+// faithful to the specification.
+var (
+	t1 [256]uint64
+	t2 [256]uint64
+	t3 [256]uint64
+	t4 [256]uint64
+)
+
+func init() {
+	fillSBox(&t1, 0x9e3779b97f4a7c15)
+	fillSBox(&t2, 0xbf58476d1ce4e5b9)
+	fillSBox(&t3, 0x94d049bb133111eb)
+	fillSBox(&t4, 0x2545f4914f6cdd1d)
+}
+
+func fillSBox(box *[256]uint64, seed uint64) {
+	x := seed
+	for i := range box {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		box[i] = z ^ (z >> 31)
+	}
+}
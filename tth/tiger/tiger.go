@@ -0,0 +1,181 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package tiger implements the round structure and key schedule of
+// the Tiger hash function of Anderson and Biham, the digest Tiger
+// Tree Hash (and so most THEX deployments in the wild, such as DC++
+// and Gnutella magnet links) is built on.
+//
+// This package is NOT yet interoperable with any other Tiger
+// implementation: see Canonical, in sbox.go, for why and what must
+// change before it is. Do not use it to produce or verify
+// DC++/Gnutella/urn:tree:tiger: hashes until the canonical tables have
+// been substituted in; until then it is only useful for exercising
+// thex's tree-building code against a 192-bit/64-byte-block digest.
+package tiger
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// Size is the size, in bytes, of a Tiger checksum.
+	Size = 24
+	// BlockSize is the block size, in bytes, of the Tiger hash function.
+	BlockSize = 64
+)
+
+type digest struct {
+	a, b, c uint64
+	buf     [BlockSize]byte
+	buflen  int
+	length  uint64
+}
+
+// New returns a new hash.Hash computing the Tiger checksum.
+//
+// See the package doc: this does not yet produce canonical Tiger
+// digests.
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+func (d *digest) Size() int      { return Size }
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Reset() {
+	d.a = 0x0123456789abcdef
+	d.b = 0xfedcba9876543210
+	d.c = 0xf096a5b4c3b2e187
+	d.buflen = 0
+	d.length = 0
+}
+
+func (d *digest) Write(p []byte) (n int, err error) {
+	n = len(p)
+	d.length += uint64(n)
+
+	if d.buflen > 0 {
+		k := copy(d.buf[d.buflen:], p)
+		d.buflen += k
+		p = p[k:]
+		if d.buflen == BlockSize {
+			d.block(d.buf[:])
+			d.buflen = 0
+		}
+	}
+	for len(p) >= BlockSize {
+		d.block(p[:BlockSize])
+		p = p[BlockSize:]
+	}
+	if len(p) > 0 {
+		d.buflen = copy(d.buf[:], p)
+	}
+	return
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	// operate on a copy so that Sum leaves d usable for further Write calls
+	final := *d
+	return final.checkSum(in)
+}
+
+// checkSum pads the buffered tail with the 0x01 byte that Tiger uses
+// in place of SHA/MD5's 0x80, followed by the bit length as a
+// little-endian uint64, then folds in the remaining blocks.
+func (d *digest) checkSum(in []byte) []byte {
+	bitLen := d.length * 8
+
+	tail := append([]byte(nil), d.buf[:d.buflen]...)
+	tail = append(tail, 0x01)
+	for len(tail)%BlockSize != BlockSize-8 {
+		tail = append(tail, 0)
+	}
+	var lenBytes [8]byte
+	binary.LittleEndian.PutUint64(lenBytes[:], bitLen)
+	tail = append(tail, lenBytes[:]...)
+
+	for len(tail) > 0 {
+		d.block(tail[:BlockSize])
+		tail = tail[BlockSize:]
+	}
+
+	var out [Size]byte
+	binary.LittleEndian.PutUint64(out[0:8], d.a)
+	binary.LittleEndian.PutUint64(out[8:16], d.b)
+	binary.LittleEndian.PutUint64(out[16:24], d.c)
+	return append(in, out[:]...)
+}
+
+func (d *digest) block(p []byte) {
+	var x [8]uint64
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint64(p[i*8:])
+	}
+
+	a, b, c := d.a, d.b, d.c
+	aa, bb, cc := a, b, c
+
+	pass(&a, &b, &c, &x, 5)
+	keySchedule(&x)
+	pass(&c, &a, &b, &x, 7)
+	keySchedule(&x)
+	pass(&b, &c, &a, &x, 9)
+
+	d.a = a ^ aa
+	d.b = b - bb
+	d.c = c + cc
+}
+
+func round(a, b, c *uint64, xi uint64, mul uint64) {
+	*c ^= xi
+	cb := *c
+	*a -= t1[byte(cb)] ^ t2[byte(cb>>16)] ^ t3[byte(cb>>32)] ^ t4[byte(cb>>48)]
+	*b += t4[byte(cb>>8)] ^ t3[byte(cb>>24)] ^ t2[byte(cb>>40)] ^ t1[byte(cb>>56)]
+	*b *= mul
+}
+
+func pass(a, b, c *uint64, x *[8]uint64, mul uint64) {
+	round(a, b, c, x[0], mul)
+	round(b, c, a, x[1], mul)
+	round(c, a, b, x[2], mul)
+	round(a, b, c, x[3], mul)
+	round(b, c, a, x[4], mul)
+	round(c, a, b, x[5], mul)
+	round(a, b, c, x[6], mul)
+	round(b, c, a, x[7], mul)
+}
+
+func keySchedule(x *[8]uint64) {
+	x[0] -= x[7] ^ 0xa5a5a5a5a5a5a5a5
+	x[1] ^= x[0]
+	x[2] += x[1]
+	x[3] -= x[2] ^ ((^x[1]) << 19)
+	x[4] ^= x[3]
+	x[5] += x[4]
+	x[6] -= x[5] ^ ((^x[4]) >> 23)
+	x[7] ^= x[6]
+	x[0] += x[7]
+	x[1] -= x[0] ^ ((^x[7]) << 19)
+	x[2] ^= x[1]
+	x[3] += x[2]
+	x[4] -= x[3] ^ ((^x[2]) >> 23)
+	x[5] ^= x[4]
+	x[6] += x[5]
+	x[7] -= x[6] ^ 0x0123456789abcdef
+}
@@ -0,0 +1,35 @@
+package tiger_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/3M3RY/go-thex/tth/tiger"
+)
+
+// TestNotCanonical pins down the known non-conformance documented by
+// tiger.Canonical: until the real tiger.c S-boxes are vendored in,
+// this package must not produce the published Tiger test vectors. If
+// this test starts failing because the digests now match, Canonical
+// should be flipped to true and this test deleted rather than fixed.
+func TestNotCanonical(t *testing.T) {
+	if tiger.Canonical {
+		t.Fatal("tiger.Canonical is true, but no canonical S-boxes have been vendored in; update this test")
+	}
+
+	cases := []struct {
+		in         string
+		wantPrefix string // leading hex digits of the published canonical digest
+	}{
+		{"", "24f0130c63ac9332"},
+		{"abc", "f258c1e88414ab2a"},
+	}
+	for _, c := range cases {
+		h := tiger.New()
+		h.Write([]byte(c.in))
+		got := hex.EncodeToString(h.Sum(nil))
+		if len(got) >= len(c.wantPrefix) && got[:len(c.wantPrefix)] == c.wantPrefix {
+			t.Fatalf("Tiger(%q) = %s, matches the canonical vector; tiger.Canonical must be updated to true", c.in, got)
+		}
+	}
+}
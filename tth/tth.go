@@ -0,0 +1,136 @@
+// Copyright © 2013 Emery Hemingway
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package tth wires a Tiger digest into thex the way Tiger Tree Hash,
+// the scheme DC++, Gnutella and urn:tree:tiger: magnet links use,
+// does. Unlike thex.New, which expects to be fed pre-hashed leaves,
+// New here accepts raw file bytes: it splits them into SegmentSize
+// chunks, hashes each with the 0x00 leaf prefix the THEX draft
+// specifies, and feeds the resulting leaves to the tree.
+//
+// This package's Tiger digest (tth/tiger) does not yet use the
+// canonical Tiger S-box constants (see tiger.Canonical), so the roots
+// New produces do NOT match real TTH roots and cannot be used to
+// verify or generate DC++, Gnutella, or urn:tree:tiger: magnet-link
+// hashes. Vendoring the canonical tables into tth/tiger is a
+// prerequisite for that; until then this package is only useful for
+// exercising the THEX tree-building and segmenting logic above a
+// Tiger-shaped digest.
+package tth
+
+import (
+	"encoding/base32"
+	"hash"
+
+	"github.com/3M3RY/go-thex"
+	"github.com/3M3RY/go-thex/tth/tiger"
+)
+
+// SegmentSize is the chunk size TTH hashes file data in, as fixed by
+// convention across DC++, Gnutella and similar tools.
+const SegmentSize = 1024
+
+var leafPrefix = []byte{0x00}
+
+// compactThreshold bounds how far off can drift into buf before Write
+// compacts it, so a long-running stream of small writes can't grow
+// buf without bound even if it never lands exactly on a segment
+// boundary.
+const compactThreshold = 1 << 20
+
+type hasher struct {
+	tree  hash.Hash
+	leaf  hash.Hash
+	buf   []byte
+	off   int  // bytes of buf already hashed into a segment
+	wrote bool // whether Write has been called at least once
+	done  bool
+}
+
+// New returns a hash.Hash that computes a Tiger Tree Hash root over
+// the raw file bytes written to it.
+//
+// Sum finalizes any segment still buffered, so New should be written
+// to in full before Sum is called; further writes afterward start a
+// new, unrelated segment rather than extending the old one.
+func New() hash.Hash {
+	h := &hasher{
+		tree: thex.New(tiger.New()),
+		leaf: tiger.New(),
+	}
+	return h
+}
+
+func (h *hasher) Size() int      { return h.leaf.Size() }
+func (h *hasher) BlockSize() int { return SegmentSize }
+
+func (h *hasher) Write(p []byte) (n int, err error) {
+	n = len(p)
+	h.wrote = true
+	h.buf = append(h.buf, p...)
+	for len(h.buf)-h.off >= SegmentSize {
+		h.writeSegment(h.buf[h.off : h.off+SegmentSize])
+		h.off += SegmentSize
+	}
+	switch {
+	case h.off == len(h.buf):
+		h.buf = h.buf[:0]
+		h.off = 0
+	case h.off >= compactThreshold:
+		h.buf = append(h.buf[:0], h.buf[h.off:]...)
+		h.off = 0
+	}
+	return
+}
+
+func (h *hasher) writeSegment(seg []byte) {
+	h.leaf.Reset()
+	h.leaf.Write(leafPrefix)
+	h.leaf.Write(seg)
+	h.tree.Write(h.leaf.Sum(nil))
+}
+
+func (h *hasher) Sum(b []byte) []byte {
+	if !h.done {
+		// Write already hashed and cleared buf whenever the bytes
+		// written so far landed exactly on a segment boundary; in that
+		// case there is no final partial segment to hash here, only
+		// when nothing has ever been written does an empty segment
+		// still need to be produced, to match the zero-length-input
+		// vector.
+		if h.off < len(h.buf) || !h.wrote {
+			h.writeSegment(h.buf[h.off:])
+		}
+		h.buf = nil
+		h.off = 0
+		h.done = true
+	}
+	return h.tree.Sum(b)
+}
+
+func (h *hasher) Reset() {
+	h.tree = thex.New(tiger.New())
+	h.leaf = tiger.New()
+	h.buf = nil
+	h.off = 0
+	h.wrote = false
+	h.done = false
+}
+
+// RootString formats a TTH root the way magnet links and DC++ do: as
+// unpadded, upper-case Base32.
+func RootString(root []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(root)
+}
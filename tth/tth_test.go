@@ -0,0 +1,78 @@
+package tth_test
+
+import (
+	"testing"
+
+	"github.com/3M3RY/go-thex"
+	"github.com/3M3RY/go-thex/tth"
+	"github.com/3M3RY/go-thex/tth/tiger"
+)
+
+// manualRoot computes the TTH root the same way New does internally
+// (split into SegmentSize chunks, each hashed with the 0x00 leaf
+// prefix) but without sharing any of New's buffering logic, so it can
+// catch a bug in how New decides where segment boundaries fall.
+func manualRoot(data []byte) []byte {
+	tree := thex.New(tiger.New())
+	leaf := tiger.New()
+	for len(data) > 0 {
+		n := tth.SegmentSize
+		if n > len(data) {
+			n = len(data)
+		}
+		leaf.Reset()
+		leaf.Write([]byte{0x00})
+		leaf.Write(data[:n])
+		tree.Write(leaf.Sum(nil))
+		data = data[n:]
+	}
+	return tree.Sum(nil)
+}
+
+func TestWriteSegmentAlignment(t *testing.T) {
+	sizes := []int{0, 1, tth.SegmentSize - 1, tth.SegmentSize, tth.SegmentSize + 1, 2 * tth.SegmentSize, 2*tth.SegmentSize + 1}
+	for _, n := range sizes {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i)
+		}
+
+		h := tth.New()
+		h.Write(data)
+		got := h.Sum(nil)
+
+		want := manualRoot(data)
+		if string(got) != string(want) {
+			t.Errorf("n=%d: root = %x, want %x", n, got, want)
+		}
+	}
+}
+
+// TestWriteChunkingAgnostic checks that splitting the same input
+// across many small Write calls produces the same root as one large
+// Write, including right at a segment boundary.
+func TestWriteChunkingAgnostic(t *testing.T) {
+	data := make([]byte, 3*tth.SegmentSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	whole := tth.New()
+	whole.Write(data)
+	want := whole.Sum(nil)
+
+	for _, chunk := range []int{1, 3, 7, tth.SegmentSize - 1, tth.SegmentSize, tth.SegmentSize + 1} {
+		h := tth.New()
+		for off := 0; off < len(data); off += chunk {
+			end := off + chunk
+			if end > len(data) {
+				end = len(data)
+			}
+			h.Write(data[off:end])
+		}
+		got := h.Sum(nil)
+		if string(got) != string(want) {
+			t.Errorf("chunk=%d: root = %x, want %x", chunk, got, want)
+		}
+	}
+}